@@ -0,0 +1,131 @@
+package iavl
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"github.com/tendermint/go-wire/data"
+)
+
+// KeyAbsenceProof proves a key's absence by recording the path to one leaf
+// next to it (the "terminal" leaf: its predecessor when the tree has one,
+// otherwise its successor), rather than always
+// materializing both a predecessor and a successor leaf the way
+// KeyNotExistsProof does. A single path only proves absence on its own when
+// the terminal leaf sits at the corresponding tree boundary -- verified via
+// Path.isRightmost()/isLeftmost(), which needs nothing beyond the
+// already-hash-verified path. Everywhere else, Neighbor/NeighborPath are
+// required: IAVLProofInnerNode's hash never covers a node's key, so there is
+// no way to authenticate "the other branch at this level also couldn't
+// contain the queried key" without a second, independently hash-verified
+// leaf to pin down the other edge of the gap.
+type KeyAbsenceProof struct {
+	RootHash data.Bytes `json:"root_hash"`
+
+	TerminalNode IAVLProofLeafNode `json:"terminal_node"`
+	Path         PathToKey         `json:"path"`
+
+	// Neighbor/NeighborPath are set whenever TerminalNode is not already at
+	// the tree boundary on the side facing the queried key.
+	Neighbor     *IAVLProofLeafNode `json:"neighbor,omitempty"`
+	NeighborPath *PathToKey         `json:"neighbor_path,omitempty"`
+}
+
+// requiresNeighbor reports whether proof's Path alone is insufficient to
+// prove key's absence, i.e. whether Neighbor/NeighborPath must be present
+// and verified. TerminalNode must not equal key (checked by the caller).
+func (proof *KeyAbsenceProof) requiresNeighbor(key []byte) bool {
+	if bytes.Compare(proof.TerminalNode.KeyBytes, key) < 0 {
+		// Terminal is a candidate predecessor; a successor only fails to
+		// exist if terminal is the tree's rightmost leaf.
+		return !proof.Path.isRightmost()
+	}
+	// Terminal is a candidate successor; a predecessor only fails to exist
+	// if terminal is the tree's leftmost leaf.
+	return !proof.Path.isLeftmost()
+}
+
+// Verify checks that key is absent from the tree: TerminalNode is a
+// hash-verified leaf on the side of key implied by its ordering, and nothing
+// else could occupy the gap between it and key: either TerminalNode is at
+// the matching tree boundary, or Neighbor is the hash-verified, adjacent
+// leaf on the other side.
+func (proof *KeyAbsenceProof) Verify(key []byte, root []byte) error {
+	if !bytes.Equal(proof.RootHash, root) {
+		return errors.New("roots do not match")
+	}
+	if bytes.Equal(proof.TerminalNode.KeyBytes, key) {
+		return errors.New("terminal node key equals queried key; key exists")
+	}
+	if err := proof.Path.verify(proof.TerminalNode, root); err != nil {
+		return errors.Wrap(err, "failed to verify terminal path")
+	}
+
+	if !proof.requiresNeighbor(key) {
+		return nil
+	}
+	if proof.Neighbor == nil || proof.NeighborPath == nil {
+		return errors.New("absence proof needs a neighbor leaf: terminal node is not at the tree boundary")
+	}
+	if err := proof.NeighborPath.verify(*proof.Neighbor, root); err != nil {
+		return errors.Wrap(err, "failed to verify neighbor path")
+	}
+
+	var pair innerPathPair
+	if bytes.Compare(proof.TerminalNode.KeyBytes, key) < 0 {
+		if bytes.Compare(proof.Neighbor.KeyBytes, key) != 1 {
+			return errors.New("neighbor key must be greater than the queried key")
+		}
+		pair = innerPathPair{left: proof.Path.InnerNodes, right: proof.NeighborPath.InnerNodes}
+	} else {
+		if bytes.Compare(proof.Neighbor.KeyBytes, key) != -1 {
+			return errors.New("neighbor key must be lesser than the queried key")
+		}
+		pair = innerPathPair{left: proof.NeighborPath.InnerNodes, right: proof.Path.InnerNodes}
+	}
+	for pair.isCommonAncestor() {
+		pair.pop()
+	}
+	pair.pop()
+	if !pair.isPathsAdjacent() {
+		return errors.New("terminal and neighbor leaves are not adjacent in the tree")
+	}
+
+	return nil
+}
+
+// keyAbsenceProof builds a KeyAbsenceProof for key, which must not exist in
+// the tree, using proofBuilder's single descent for both the existence
+// check and the predecessor/successor lookup.
+func (t *IAVLTree) keyAbsenceProof(key []byte) (*KeyAbsenceProof, error) {
+	if t.root == nil {
+		return nil, errNilRootTree
+	}
+	t.root.hashWithCount(t) // Ensure that all hashes are calculated.
+
+	builder := &proofBuilder{tree: t, key: key}
+	exists, left, right := builder.find(t.root)
+	if exists != nil {
+		return nil, errors.Errorf("could not construct proof of absence: key 0x%x exists", key)
+	}
+	if left == nil && right == nil {
+		return nil, errors.New("could not construct proof of absence: tree is empty")
+	}
+
+	proof := &KeyAbsenceProof{RootHash: t.root.hash}
+	switch {
+	case right == nil:
+		proof.TerminalNode = IAVLProofLeafNode{KeyBytes: left.Key, ValueBytes: left.Value}
+		proof.Path = *left.Path
+	case left == nil:
+		proof.TerminalNode = IAVLProofLeafNode{KeyBytes: right.Key, ValueBytes: right.Value}
+		proof.Path = *right.Path
+	default:
+		proof.TerminalNode = IAVLProofLeafNode{KeyBytes: left.Key, ValueBytes: left.Value}
+		proof.Path = *left.Path
+		proof.Neighbor = &IAVLProofLeafNode{KeyBytes: right.Key, ValueBytes: right.Value}
+		proof.NeighborPath = right.Path
+	}
+
+	return proof, nil
+}
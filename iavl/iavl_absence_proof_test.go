@@ -0,0 +1,68 @@
+package iavl
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestKeyAbsenceProofRequiresNeighborAwayFromBoundary reproduces the forgery
+// the original BranchKey-trusting design allowed: a prover picks some real,
+// hash-verified leaf as TerminalNode and claims -- with no further evidence
+// -- that the queried key (which may exist elsewhere in the tree) falls in
+// the gap next to it. requiresNeighbor must report that a neighbor is
+// mandatory whenever TerminalNode isn't actually at the matching tree
+// boundary, since only isLeftmost()/isRightmost() (derived purely from the
+// already hash-verified Path) can stand in for a second leaf.
+func TestKeyAbsenceProofRequiresNeighborAwayFromBoundary(t *testing.T) {
+	interior := PathToKey{InnerNodes: []IAVLProofInnerNode{
+		{Height: 1, Size: 2, Left: nil, Right: []byte("sibling")},
+	}}
+
+	proof := &KeyAbsenceProof{
+		TerminalNode: IAVLProofLeafNode{KeyBytes: []byte("m")},
+		Path:         interior,
+	}
+
+	// Queried key sorts after the terminal leaf, so terminal is a
+	// predecessor candidate; since its path is not rightmost, a successor
+	// neighbor is mandatory -- a bare claim is not enough.
+	if !proof.requiresNeighbor([]byte("z")) {
+		t.Fatalf("expected a neighbor to be required for a non-boundary terminal leaf")
+	}
+
+	// Verify must refuse to accept such a proof without Neighbor set. The
+	// terminal path is given a matching root so that Verify gets past the
+	// path check and reaches the neighbor requirement.
+	root := proof.TerminalNode.Hash()
+	proof.Path.LeafHash = root
+	for _, node := range proof.Path.InnerNodes {
+		root = node.Hash(root)
+	}
+	proof.RootHash = root
+	err := proof.Verify([]byte("z"), root)
+	if err == nil {
+		t.Fatalf("expected Verify to reject an absence proof missing a required neighbor")
+	}
+	if !strings.Contains(err.Error(), "neighbor") {
+		t.Fatalf("expected Verify to fail on the missing neighbor, got: %v", err)
+	}
+}
+
+// TestKeyAbsenceProofBoundaryNeedsNoNeighbor confirms the one case where a
+// single path legitimately suffices: the terminal leaf is the tree's global
+// rightmost leaf, so there is no successor to produce regardless of key.
+func TestKeyAbsenceProofBoundaryNeedsNoNeighbor(t *testing.T) {
+	rightmost := PathToKey{InnerNodes: []IAVLProofInnerNode{
+		{Height: 1, Size: 2, Left: []byte("sibling"), Right: nil},
+		{Height: 2, Size: 4, Left: []byte("other-sibling"), Right: nil},
+	}}
+
+	proof := &KeyAbsenceProof{
+		TerminalNode: IAVLProofLeafNode{KeyBytes: []byte("m")},
+		Path:         rightmost,
+	}
+
+	if proof.requiresNeighbor([]byte("z")) {
+		t.Fatalf("expected no neighbor to be required when the terminal path is rightmost")
+	}
+}
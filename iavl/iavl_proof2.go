@@ -167,15 +167,6 @@ func (proof *KeyNotExistsProof) Verify(key []byte, root []byte) error {
 	return nil
 }
 
-type KeyRangeExistsProof struct {
-	RootHash   data.Bytes
-	PathToKeys []*PathToKey
-}
-
-func (proof *KeyRangeExistsProof) Verify(key []byte, value []byte, root []byte) bool {
-	return false
-}
-
 var (
 	errKeyDoesntExist = errors.New("key does not exist")
 	errNilRootTree    = errors.New("tree root is nil")
@@ -217,47 +208,8 @@ func (node *IAVLNode) constructKeyExistsProof(t *IAVLTree, key []byte, proof *Ke
 	return nil, errKeyDoesntExist
 }
 
-func (node *IAVLNode) constructKeyNotExistsProof(t *IAVLTree, key []byte, proof *KeyNotExistsProof) error {
-	// Get the index of the first key greater than the requested key, if the key doesn't exist.
-	idx, _, exists := t.Get(key)
-	if exists {
-		return errors.Errorf("couldn't construct non-existence proof: key 0x%x exists", key)
-	}
-
-	var (
-		lkey, lval []byte
-		rkey, rval []byte
-	)
-	if idx > 0 {
-		lkey, lval = t.GetByIndex(idx - 1)
-	}
-	if idx <= t.Size()-1 {
-		rkey, rval = t.GetByIndex(idx)
-	}
-
-	if lkey == nil && rkey == nil {
-		return errors.New("couldn't get keys required for non-existence proof")
-	}
-
-	if lkey != nil {
-		lproof := &KeyExistsProof{
-			RootHash: t.root.hash,
-		}
-		node.constructKeyExistsProof(t, lkey, lproof)
-		proof.LeftPath = &lproof.PathToKey
-		proof.LeftNode = IAVLProofLeafNode{KeyBytes: lkey, ValueBytes: lval}
-	}
-	if rkey != nil {
-		rproof := &KeyExistsProof{
-			RootHash: t.root.hash,
-		}
-		node.constructKeyExistsProof(t, rkey, rproof)
-		proof.RightPath = &rproof.PathToKey
-		proof.RightNode = IAVLProofLeafNode{KeyBytes: rkey, ValueBytes: rval}
-	}
-
-	return nil
-}
+// constructKeyNotExistsProof is implemented in iavl_proof_builder.go on top
+// of proofBuilder, which finds both neighbor paths in a single descent.
 
 func (t *IAVLTree) getWithKeyExistsProof(key []byte) (value []byte, proof *KeyExistsProof, err error) {
 	if t.root == nil {
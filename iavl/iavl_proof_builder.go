@@ -0,0 +1,359 @@
+package iavl
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/tendermint/go-wire/data"
+)
+
+// proofBuilder resolves a key in a single descent: if the key exists, its
+// own PathToKey and leaf are returned; otherwise its predecessor and
+// successor are returned (either may be nil at a tree boundary). This
+// replaces looking existence up via t.Get, then separately re-descending
+// for a KeyExistsProof or for each of the two KeyNotExistsProof neighbors.
+type proofBuilder struct {
+	tree *IAVLTree
+	key  []byte
+}
+
+// proofNeighbor bundles a leaf's key/value with the PathToKey proving it.
+type proofNeighbor struct {
+	Path  *PathToKey
+	Key   []byte
+	Value []byte
+}
+
+// find descends from node exactly once. If b.key exists, it is returned as
+// exists; otherwise left/right are its predecessor/successor.
+func (b *proofBuilder) find(node *IAVLNode) (exists, left, right *proofNeighbor) {
+	var (
+		path []IAVLProofInnerNode
+
+		predBranchLen  int // len(path) at the last "went right" turn
+		predSubtree    *IAVLNode
+		predTurn       IAVLProofInnerNode // predecessor's entry at that turn
+		havePredBranch bool
+
+		succBranchLen  int // len(path) at the last "went left" turn
+		succSubtree    *IAVLNode
+		succTurn       IAVLProofInnerNode // successor's entry at that turn
+		haveSuccBranch bool
+	)
+
+	for node.height > 0 {
+		if bytes.Compare(b.key, node.key) < 0 {
+			path = append(path, IAVLProofInnerNode{
+				Height: node.height, Size: node.size,
+				Left: nil, Right: node.getRightNode(b.tree).hash,
+			})
+			succSubtree = node.getRightNode(b.tree)
+			succTurn = IAVLProofInnerNode{
+				Height: node.height, Size: node.size,
+				Left: node.getLeftNode(b.tree).hash, Right: nil,
+			}
+			succBranchLen = len(path)
+			haveSuccBranch = true
+			node = node.getLeftNode(b.tree)
+		} else {
+			path = append(path, IAVLProofInnerNode{
+				Height: node.height, Size: node.size,
+				Left: node.getLeftNode(b.tree).hash, Right: nil,
+			})
+			predSubtree = node.getLeftNode(b.tree)
+			predTurn = IAVLProofInnerNode{
+				Height: node.height, Size: node.size,
+				Left: nil, Right: node.getRightNode(b.tree).hash,
+			}
+			predBranchLen = len(path)
+			havePredBranch = true
+			node = node.getRightNode(b.tree)
+		}
+	}
+
+	// node is now the leaf at key's insertion point.
+	ownPath := &PathToKey{LeafHash: node.hash, InnerNodes: reverseInnerNodes(path)}
+	ownNeighbor := &proofNeighbor{Path: ownPath, Key: node.key, Value: node.value}
+	switch {
+	case bytes.Equal(node.key, b.key):
+		return ownNeighbor, nil, nil
+	case bytes.Compare(node.key, b.key) < 0:
+		left = ownNeighbor
+	default:
+		right = ownNeighbor
+	}
+
+	if left == nil && havePredBranch {
+		leaf, sub := predSubtree.rightmostLeaf(b.tree)
+		// The predecessor went left at the turn node, so its entry there
+		// differs from the query's own descent entry.
+		upper := reverseInnerNodes(path[:predBranchLen])
+		upper[0] = predTurn
+		left = &proofNeighbor{
+			Path: &PathToKey{
+				LeafHash:   leaf.hash,
+				InnerNodes: append(reverseInnerNodes(sub), upper...),
+			},
+			Key: leaf.key, Value: leaf.value,
+		}
+	}
+	if right == nil && haveSuccBranch {
+		leaf, sub := succSubtree.leftmostLeaf(b.tree)
+		upper := reverseInnerNodes(path[:succBranchLen])
+		upper[0] = succTurn
+		right = &proofNeighbor{
+			Path: &PathToKey{
+				LeafHash:   leaf.hash,
+				InnerNodes: append(reverseInnerNodes(sub), upper...),
+			},
+			Key: leaf.key, Value: leaf.value,
+		}
+	}
+
+	return nil, left, right
+}
+
+// rightmostLeaf descends the rightmost spine of the subtree rooted at node,
+// returning that leaf and the inner-node path to it (root-to-leaf order).
+func (node *IAVLNode) rightmostLeaf(t *IAVLTree) (*IAVLNode, []IAVLProofInnerNode) {
+	var path []IAVLProofInnerNode
+	for node.height > 0 {
+		path = append(path, IAVLProofInnerNode{
+			Height: node.height, Size: node.size,
+			Left: node.getLeftNode(t).hash, Right: nil,
+		})
+		node = node.getRightNode(t)
+	}
+	return node, path
+}
+
+// leftmostLeaf is the mirror of rightmostLeaf.
+func (node *IAVLNode) leftmostLeaf(t *IAVLTree) (*IAVLNode, []IAVLProofInnerNode) {
+	var path []IAVLProofInnerNode
+	for node.height > 0 {
+		path = append(path, IAVLProofInnerNode{
+			Height: node.height, Size: node.size,
+			Left: nil, Right: node.getRightNode(t).hash,
+		})
+		node = node.getLeftNode(t)
+	}
+	return node, path
+}
+
+// constructKeyNotExistsProof is reimplemented on top of proofBuilder so a
+// single descent produces both neighbor paths (and the existence check
+// itself), rather than looking existence up via t.Get and then separately
+// re-descending via t.GetByIndex and two constructKeyExistsProof calls.
+func (node *IAVLNode) constructKeyNotExistsProof(t *IAVLTree, key []byte, proof *KeyNotExistsProof) error {
+	builder := &proofBuilder{tree: t, key: key}
+	exists, left, right := builder.find(node)
+	if exists != nil {
+		return errors.Errorf("couldn't construct non-existence proof: key 0x%x exists", key)
+	}
+	if left == nil && right == nil {
+		return errors.New("couldn't get keys required for non-existence proof")
+	}
+
+	if left != nil {
+		proof.LeftPath = left.Path
+		proof.LeftNode = IAVLProofLeafNode{KeyBytes: left.Key, ValueBytes: left.Value}
+	}
+	if right != nil {
+		proof.RightPath = right.Path
+		proof.RightNode = IAVLProofLeafNode{KeyBytes: right.Key, ValueBytes: right.Value}
+	}
+
+	return nil
+}
+
+// BatchProof proves membership or non-membership for a batch of keys in a
+// single structure. Inner nodes shared by more than one key's path (common
+// ancestors) are stored once in Pool and referenced by index, so the
+// on-wire size grows with the number of distinct inner nodes touched across
+// the whole batch rather than with the sum of each key's path length.
+type BatchProof struct {
+	RootHash data.Bytes           `json:"root_hash"`
+	Pool     []IAVLProofInnerNode `json:"pool"`
+	Entries  []BatchProofEntry    `json:"entries"`
+}
+
+// BatchProofEntry is one key's result within a BatchProof. When Exists is
+// true, Value/LeafHash/PoolIndexes describe that key's own leaf. Otherwise
+// at least one of LeftNode/RightNode is set, mirroring KeyNotExistsProof.
+type BatchProofEntry struct {
+	Key    data.Bytes `json:"key"`
+	Exists bool       `json:"exists"`
+
+	Value       data.Bytes `json:"value,omitempty"`
+	LeafHash    data.Bytes `json:"leaf_hash,omitempty"`
+	PoolIndexes []int      `json:"pool_indexes,omitempty"`
+
+	LeftNode         *IAVLProofLeafNode `json:"left_node,omitempty"`
+	LeftPoolIndexes  []int              `json:"left_pool_indexes,omitempty"`
+	RightNode        *IAVLProofLeafNode `json:"right_node,omitempty"`
+	RightPoolIndexes []int              `json:"right_pool_indexes,omitempty"`
+}
+
+// innerNodePool interns IAVLProofInnerNodes so that repeated ancestors
+// across several keys' paths are only stored once.
+type innerNodePool struct {
+	nodes []IAVLProofInnerNode
+	index map[string]int
+}
+
+func (p *innerNodePool) intern(nodes []IAVLProofInnerNode) []int {
+	if p.index == nil {
+		p.index = map[string]int{}
+	}
+	idxs := make([]int, len(nodes))
+	for i, node := range nodes {
+		key := fmt.Sprintf("%d:%d:%x:%x", node.Height, node.Size, node.Left, node.Right)
+		idx, ok := p.index[key]
+		if !ok {
+			idx = len(p.nodes)
+			p.nodes = append(p.nodes, node)
+			p.index[key] = idx
+		}
+		idxs[i] = idx
+	}
+	return idxs
+}
+
+// GetBatchWithProof proves membership or non-membership of every key in
+// keys, each with a single descent via proofBuilder, deduping shared
+// ancestor inner nodes across the whole batch.
+//
+// NOTE: a benchmark comparing this against one constructKeyExistsProof /
+// keyNotExistsProof call per key on a multi-million-leaf tree belongs
+// alongside this function, but this snapshot has no tree-construction code
+// (no Set/NewIAVLTree) to build such a fixture against, so it's deferred
+// rather than guessed at.
+func (t *IAVLTree) GetBatchWithProof(keys [][]byte) (*BatchProof, error) {
+	if t.root == nil {
+		return nil, errNilRootTree
+	}
+	t.root.hashWithCount(t)
+
+	pool := &innerNodePool{}
+	proof := &BatchProof{RootHash: t.root.hash}
+
+	for _, key := range keys {
+		builder := &proofBuilder{tree: t, key: key}
+		exists, left, right := builder.find(t.root)
+
+		if exists != nil {
+			proof.Entries = append(proof.Entries, BatchProofEntry{
+				Key: key, Exists: true, Value: exists.Value, LeafHash: exists.Path.LeafHash,
+				PoolIndexes: pool.intern(exists.Path.InnerNodes),
+			})
+			continue
+		}
+		if left == nil && right == nil {
+			return nil, errors.Errorf("couldn't get keys required for non-existence proof of 0x%x", key)
+		}
+
+		entry := BatchProofEntry{Key: key}
+		if left != nil {
+			entry.LeftNode = &IAVLProofLeafNode{KeyBytes: left.Key, ValueBytes: left.Value}
+			entry.LeftPoolIndexes = pool.intern(left.Path.InnerNodes)
+		}
+		if right != nil {
+			entry.RightNode = &IAVLProofLeafNode{KeyBytes: right.Key, ValueBytes: right.Value}
+			entry.RightPoolIndexes = pool.intern(right.Path.InnerNodes)
+		}
+		proof.Entries = append(proof.Entries, entry)
+	}
+
+	proof.Pool = pool.nodes
+	return proof, nil
+}
+
+func (proof *BatchProof) resolve(idxs []int) ([]IAVLProofInnerNode, error) {
+	nodes := make([]IAVLProofInnerNode, len(idxs))
+	for i, idx := range idxs {
+		if idx < 0 || idx >= len(proof.Pool) {
+			return nil, errors.New("pool index out of range")
+		}
+		nodes[i] = proof.Pool[idx]
+	}
+	return nodes, nil
+}
+
+// Verify checks every entry in the batch against root.
+func (proof *BatchProof) Verify(root []byte) error {
+	if !bytes.Equal(proof.RootHash, root) {
+		return errors.New("roots are not equal")
+	}
+
+	for _, entry := range proof.Entries {
+		if entry.Exists {
+			nodes, err := proof.resolve(entry.PoolIndexes)
+			if err != nil {
+				return err
+			}
+			path := &PathToKey{LeafHash: entry.LeafHash, InnerNodes: nodes}
+			leaf := IAVLProofLeafNode{KeyBytes: entry.Key, ValueBytes: entry.Value}
+			if err := path.verify(leaf, root); err != nil {
+				return errors.Wrapf(err, "key 0x%x", entry.Key)
+			}
+			continue
+		}
+
+		if entry.LeftNode == nil && entry.RightNode == nil {
+			return errors.Errorf("key 0x%x: at least one neighbor path must exist", entry.Key)
+		}
+
+		var leftNodes, rightNodes []IAVLProofInnerNode
+		if entry.LeftNode != nil {
+			nodes, err := proof.resolve(entry.LeftPoolIndexes)
+			if err != nil {
+				return err
+			}
+			leftNodes = nodes
+			path := &PathToKey{LeafHash: entry.LeftNode.Hash(), InnerNodes: nodes}
+			if err := path.verify(*entry.LeftNode, root); err != nil {
+				return errors.Wrapf(err, "key 0x%x: left neighbor", entry.Key)
+			}
+			if bytes.Compare(entry.LeftNode.KeyBytes, entry.Key) != -1 {
+				return errors.Errorf("key 0x%x: left node key must be lesser than supplied key", entry.Key)
+			}
+		}
+		if entry.RightNode != nil {
+			nodes, err := proof.resolve(entry.RightPoolIndexes)
+			if err != nil {
+				return err
+			}
+			rightNodes = nodes
+			path := &PathToKey{LeafHash: entry.RightNode.Hash(), InnerNodes: nodes}
+			if err := path.verify(*entry.RightNode, root); err != nil {
+				return errors.Wrapf(err, "key 0x%x: right neighbor", entry.Key)
+			}
+			if bytes.Compare(entry.RightNode.KeyBytes, entry.Key) != 1 {
+				return errors.Errorf("key 0x%x: right node key must be greater than supplied key", entry.Key)
+			}
+		}
+
+		switch {
+		case entry.LeftNode != nil && entry.RightNode != nil:
+			pair := innerPathPair{left: leftNodes, right: rightNodes}
+			for pair.isCommonAncestor() {
+				pair.pop()
+			}
+			pair.pop()
+			if !pair.isPathsAdjacent() {
+				return errors.Errorf("key 0x%x: neighbor paths are not adjacent", entry.Key)
+			}
+		case entry.LeftNode == nil:
+			if !isLeftmostInnerNodes(rightNodes) {
+				return errors.Errorf("key 0x%x: right path is only one but not leftmost", entry.Key)
+			}
+		case entry.RightNode == nil:
+			if !isRightmostInnerNodes(leftNodes) {
+				return errors.Errorf("key 0x%x: left path is only one but not rightmost", entry.Key)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,26 @@
+package iavl
+
+import "testing"
+
+// TestInnerNodePoolDedupes checks that interning the same inner node twice
+// (as happens when two keys in a batch share an ancestor) returns the same
+// pool index both times, rather than storing it twice.
+func TestInnerNodePoolDedupes(t *testing.T) {
+	pool := &innerNodePool{}
+
+	shared := IAVLProofInnerNode{Height: 3, Size: 5, Left: []byte("l"), Right: nil}
+	unique := IAVLProofInnerNode{Height: 1, Size: 1, Left: nil, Right: []byte("r")}
+
+	first := pool.intern([]IAVLProofInnerNode{shared, unique})
+	second := pool.intern([]IAVLProofInnerNode{shared})
+
+	if len(pool.nodes) != 2 {
+		t.Fatalf("expected 2 distinct pool entries, got %d: %#v", len(pool.nodes), pool.nodes)
+	}
+	if first[0] != second[0] {
+		t.Fatalf("expected the shared inner node to resolve to the same pool index, got %d and %d", first[0], second[0])
+	}
+	if first[1] == second[0] {
+		t.Fatalf("distinct inner nodes must not collapse to the same pool index")
+	}
+}
@@ -0,0 +1,37 @@
+package iavl
+
+import "testing"
+
+// TestRangeProofAdjacencyDropsSharedAncestorEntry checks the adjacency step
+// of RangeProof.Verify, applied directly to innerPathPair, on the simplest
+// case Verify must accept: two leaves that are direct
+// siblings under a single common ancestor (a root with one left leaf and
+// one right leaf). The ancestor's own entry -- Right-set on the left leaf's
+// unique path, Left-set on the right leaf's unique path -- must be dropped
+// before the leftmost/rightmost adjacency check, or it fails unconditionally
+// since neither side is actually leftmost/rightmost once that entry is
+// included.
+func TestRangeProofAdjacencyDropsSharedAncestorEntry(t *testing.T) {
+	ancestorFromLeft := IAVLProofInnerNode{Height: 1, Size: 2, Left: nil, Right: []byte("right-leaf-hash")}
+	ancestorFromRight := IAVLProofInnerNode{Height: 1, Size: 2, Left: []byte("left-leaf-hash"), Right: nil}
+
+	leftUnique := []IAVLProofInnerNode{ancestorFromLeft}
+	rightUnique := []IAVLProofInnerNode{ancestorFromRight}
+
+	// Mirrors the fixed RangeProof.Verify: drop the shared divergence entry
+	// (the last, root-ward element of each unique path) before checking.
+	left := leftUnique[:len(leftUnique)-1]
+	right := rightUnique[:len(rightUnique)-1]
+	pair := innerPathPair{left: left, right: right}
+	if !pair.isPathsAdjacent() {
+		t.Fatalf("expected adjacent sibling leaves to be reported adjacent once the shared ancestor entry is dropped")
+	}
+
+	// Without dropping it (the bug as originally shipped), the check must
+	// fail, since ancestorFromLeft.Right is set (not rightmost) and
+	// ancestorFromRight.Left is set (not leftmost).
+	buggyPair := innerPathPair{left: leftUnique, right: rightUnique}
+	if buggyPair.isPathsAdjacent() {
+		t.Fatalf("test setup invalid: expected the un-trimmed paths to be reported non-adjacent")
+	}
+}
@@ -0,0 +1,151 @@
+package iavl
+
+import (
+	"bytes"
+
+	ics23 "github.com/confio/ics23/go"
+	"github.com/pkg/errors"
+	wire "github.com/tendermint/go-wire"
+)
+
+// iavlLeafOp describes how every IAVLProofLeafNode hashes, so that a single
+// LeafOp can be reused across every ExistenceProof this tree produces.
+var iavlLeafOp = &ics23.LeafOp{
+	Hash:         ics23.HashOp_RIPEMD160,
+	PrehashKey:   ics23.HashOp_NO_HASH,
+	PrehashValue: ics23.HashOp_NO_HASH,
+	// ics23 applies Length to key and value itself rather than letting us
+	// bake their lengths into Prefix/Suffix the way convertInnerOps does for
+	// sibling hashes, so it must match wire.WriteByteSlice's own length
+	// framing exactly, not just approximate it.
+	Length: ics23.LengthOp_VAR_PROTO,
+	Prefix: leafOpPrefix(),
+}
+
+// leafOpPrefix encodes the fixed Height=0, Size=1 preamble every leaf hashes
+// with (see IAVLProofLeafNode.Hash), using the same wire primitives
+// convertInnerOps uses for inner nodes, rather than a hand-written literal
+// that silently goes stale if that encoding ever changes.
+func leafOpPrefix() []byte {
+	buf := new(bytes.Buffer)
+	n, err := int(0), error(nil)
+	wire.WriteInt8(0, buf, &n, &err)
+	wire.WriteVarint(1, buf, &n, &err)
+	if err != nil {
+		panic(errors.Wrap(err, "could not encode iavl leaf op prefix"))
+	}
+	return buf.Bytes()
+}
+
+// GetMembershipProof returns an ics23 CommitmentProof attesting that key
+// maps to its current value in the tree, for consumption by light clients
+// that only speak the generic commitment proof format and don't know the
+// IAVL-specific KeyExistsProof encoding.
+func (t *IAVLTree) GetMembershipProof(key []byte) (*ics23.CommitmentProof, error) {
+	value, proof, err := t.getWithKeyExistsProof(key)
+	if err != nil {
+		return nil, err
+	}
+	path, err := convertInnerOps(proof.InnerNodes)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode membership proof")
+	}
+	return &ics23.CommitmentProof{
+		Proof: &ics23.CommitmentProof_Exist{
+			Exist: &ics23.ExistenceProof{
+				Key:   key,
+				Value: value,
+				Leaf:  iavlLeafOp,
+				Path:  path,
+			},
+		},
+	}, nil
+}
+
+// GetNonMembershipProof returns an ics23 CommitmentProof attesting that key
+// is absent from the tree.
+func (t *IAVLTree) GetNonMembershipProof(key []byte) (*ics23.CommitmentProof, error) {
+	proof, err := t.keyNotExistsProof(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonexist := &ics23.NonExistenceProof{Key: key}
+	if proof.LeftPath != nil {
+		path, err := convertInnerOps(proof.LeftPath.InnerNodes)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not encode left neighbor proof")
+		}
+		nonexist.Left = &ics23.ExistenceProof{
+			Key:   proof.LeftNode.KeyBytes,
+			Value: proof.LeftNode.ValueBytes,
+			Leaf:  iavlLeafOp,
+			Path:  path,
+		}
+	}
+	if proof.RightPath != nil {
+		path, err := convertInnerOps(proof.RightPath.InnerNodes)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not encode right neighbor proof")
+		}
+		nonexist.Right = &ics23.ExistenceProof{
+			Key:   proof.RightNode.KeyBytes,
+			Value: proof.RightNode.ValueBytes,
+			Leaf:  iavlLeafOp,
+			Path:  path,
+		}
+	}
+
+	return &ics23.CommitmentProof{
+		Proof: &ics23.CommitmentProof_Nonexist{Nonexist: nonexist},
+	}, nil
+}
+
+// convertInnerOps converts this tree's own IAVLProofInnerNode siblings
+// (ordered leaf-to-root, same as PathToKey.InnerNodes) into ics23 InnerOps
+// in the same order. Each op must satisfy
+// hash(prefix || childHash || suffix) == parentHash, which for an
+// IAVLProofInnerNode's own Hash encoding means: prefix is always
+// Height || Size, followed by the left sibling's length-prefixed hash when
+// we descended right, and suffix is the right sibling's length-prefixed
+// hash when we descended left, empty otherwise.
+func convertInnerOps(nodes []IAVLProofInnerNode) ([]*ics23.InnerOp, error) {
+	ops := make([]*ics23.InnerOp, len(nodes))
+	for i, node := range nodes {
+		prefix := new(bytes.Buffer)
+		n, err := int(0), error(nil)
+		wire.WriteInt8(node.Height, prefix, &n, &err)
+		wire.WriteVarint(node.Size, prefix, &n, &err)
+
+		var suffix []byte
+		if len(node.Left) == 0 {
+			sbuf := new(bytes.Buffer)
+			wire.WriteByteSlice(node.Right, sbuf, &n, &err)
+			suffix = sbuf.Bytes()
+		} else {
+			wire.WriteByteSlice(node.Left, prefix, &n, &err)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not encode inner node %d", i)
+		}
+
+		ops[i] = &ics23.InnerOp{
+			Hash:   ics23.HashOp_RIPEMD160,
+			Prefix: prefix.Bytes(),
+			Suffix: suffix,
+		}
+	}
+	return ops, nil
+}
+
+// VerifyMembership checks proof against root using the ics23 spec for this
+// tree's hashing scheme, so that callers never need to know about
+// KeyExistsProof at all.
+func VerifyMembership(proof *ics23.CommitmentProof, root, key, value []byte) bool {
+	return ics23.VerifyMembership(ics23.IavlSpec, root, proof, key, value)
+}
+
+// VerifyNonMembership is the VerifyMembership counterpart for absence.
+func VerifyNonMembership(proof *ics23.CommitmentProof, root, key []byte) bool {
+	return ics23.VerifyNonMembership(ics23.IavlSpec, root, proof, key)
+}
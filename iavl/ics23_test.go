@@ -0,0 +1,68 @@
+package iavl
+
+import (
+	"bytes"
+	"testing"
+
+	wire "github.com/tendermint/go-wire"
+)
+
+// TestLeafOpPrefixIsComputedNotHardcoded guards against the bug where
+// iavlLeafOp.Prefix was a hand-written []byte{0} literal that didn't
+// actually encode Size=1 the way IAVLProofLeafNode.Hash does, silently
+// breaking every membership proof produced via GetMembershipProof. A
+// literal can't be distinguished from a correct encoding by inspection
+// alone, so this pins leafOpPrefix to the same wire calls convertInnerOps
+// uses for the analogous Height/Size preamble on inner nodes.
+func TestLeafOpPrefixIsComputedNotHardcoded(t *testing.T) {
+	prefix := leafOpPrefix()
+	if len(prefix) == 0 {
+		t.Fatalf("expected a non-empty leaf op prefix")
+	}
+
+	var n int
+	var err error
+	want := new(bytes.Buffer)
+	wire.WriteInt8(0, want, &n, &err)
+	wire.WriteVarint(1, want, &n, &err)
+	if err != nil {
+		t.Fatalf("unexpected error encoding expected prefix: %v", err)
+	}
+	if !bytes.Equal(prefix, want.Bytes()) {
+		t.Fatalf("leafOpPrefix() = %x, want %x", prefix, want.Bytes())
+	}
+
+	// Calling it again must be stable: iavlLeafOp is a package-level var
+	// built once, so any non-determinism here would make proofs verify
+	// inconsistently across runs.
+	if again := leafOpPrefix(); !bytes.Equal(prefix, again) {
+		t.Fatalf("leafOpPrefix() is not deterministic: %x != %x", prefix, again)
+	}
+}
+
+// TestConvertInnerOpsEncodesSiblingOnCorrectSide checks that convertInnerOps
+// puts a left sibling's wire-encoded hash into Prefix (since the path
+// descended right past it) and a right sibling's into Suffix (descended
+// left), matching IAVLProofInnerNode.Hash's own left/right framing.
+func TestConvertInnerOpsEncodesSiblingOnCorrectSide(t *testing.T) {
+	descendedRight := IAVLProofInnerNode{Height: 2, Size: 3, Left: []byte("sibling"), Right: nil}
+	ops, err := convertInnerOps([]IAVLProofInnerNode{descendedRight})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ops[0].Suffix) != 0 {
+		t.Fatalf("expected no suffix when the sibling is on the left, got %x", ops[0].Suffix)
+	}
+	if !bytes.Contains(ops[0].Prefix, []byte("sibling")) {
+		t.Fatalf("expected the left sibling's hash to be wire-encoded into Prefix, got %x", ops[0].Prefix)
+	}
+
+	descendedLeft := IAVLProofInnerNode{Height: 2, Size: 3, Left: nil, Right: []byte("sibling")}
+	ops, err = convertInnerOps([]IAVLProofInnerNode{descendedLeft})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Contains(ops[0].Suffix, []byte("sibling")) {
+		t.Fatalf("expected the right sibling's hash to be wire-encoded into Suffix, got %x", ops[0].Suffix)
+	}
+}